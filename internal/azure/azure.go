@@ -7,8 +7,14 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/subscription/armsubscription"
@@ -17,22 +23,84 @@ import (
 
 const defaultTimeout = 30 * time.Second
 
-type subscriptionID string
+// pollInterval and pollAttempts bound how long we wait for a
+// RoleAssignmentScheduleRequest to reach a terminal state.
+const (
+	pollInterval = 5 * time.Second
+	pollAttempts = 10
+)
+
+// graphMeURL and graphScope are used to resolve the signed-in user's
+// principal ID when it isn't supplied on the command line.
+const (
+	graphMeURL = "https://graph.microsoft.com/v1.0/me"
+	graphScope = "https://graph.microsoft.com/.default"
+)
+
+// Scope identifies an ARM resource hierarchy scope that PIM role
+// eligibilities and assignments can be requested against, e.g. a
+// subscription, a resource group or a management group.
+type Scope struct {
+	path string
+}
+
+// String returns the ARM scope path, e.g.
+// "/subscriptions/00000000-0000-0000-0000-000000000000".
+func (s Scope) String() string {
+	return s.path
+}
+
+// SubscriptionScope returns the Scope for the subscription with the given
+// ID.
+func SubscriptionScope(subscriptionID string) Scope {
+	return Scope{path: fmt.Sprintf("/subscriptions/%s", subscriptionID)}
+}
+
+// ResourceGroupScope returns the Scope for the resource group with the
+// given name within the subscription with the given ID.
+func ResourceGroupScope(subscriptionID, resourceGroup string) Scope {
+	return Scope{path: fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", subscriptionID, resourceGroup)}
+}
+
+// ManagementGroupScope returns the Scope for the management group with the
+// given ID.
+func ManagementGroupScope(managementGroupID string) Scope {
+	return Scope{path: fmt.Sprintf("/providers/Microsoft.Management/managementGroups/%s", managementGroupID)}
+}
+
+// ParseScope validates and wraps an arbitrary ARM scope path, e.g. one
+// supplied via a --scope flag.
+func ParseScope(path string) (Scope, error) {
+	switch {
+	case strings.HasPrefix(path, "/subscriptions/"),
+		strings.HasPrefix(path, "/providers/Microsoft.Management/managementGroups/"):
+		return Scope{path: path}, nil
+	default:
+		return Scope{}, fmt.Errorf("unsupported scope: %s", path)
+	}
+}
 
 // Session holds the active session login credentials and related settings.
 type Session struct {
 	ctx         context.Context
 	timeout     time.Duration
 	principalID string
+	tenantID    string
 	credential  *azidentity.DefaultAzureCredential
 }
 
 // NewSession returns default credentials using the information from the OS
-// environment.
-// TODO: extract the users principal id somehow. Current solution is to extract
-// it with: `az ad signed-in-user show | jq .id`
-func NewSession(ctx context.Context, principalID string) (*Session, error) {
-	cred, err := azidentity.NewDefaultAzureCredential(nil)
+// environment. principalID may be left empty for commands that don't need
+// it (e.g. "subscriptions", "roles"); it is then resolved lazily, on first
+// use, via the Microsoft Graph "/me" endpoint for the signed-in user and
+// cached on disk keyed by tenantID so subsequent runs skip the round-trip.
+// tenantID may be left empty to use the credential's default tenant.
+func NewSession(ctx context.Context, principalID, tenantID string) (*Session, error) {
+	var opts *azidentity.DefaultAzureCredentialOptions
+	if tenantID != "" {
+		opts = &azidentity.DefaultAzureCredentialOptions{TenantID: tenantID}
+	}
+	cred, err := azidentity.NewDefaultAzureCredential(opts)
 	if err != nil {
 		return nil, err
 	}
@@ -40,12 +108,151 @@ func NewSession(ctx context.Context, principalID string) (*Session, error) {
 		ctx:         ctx,
 		timeout:     defaultTimeout,
 		principalID: principalID,
+		tenantID:    tenantID,
 		credential:  cred,
 	}, nil
 }
 
-// Subscription returns all enabled subscriptions.
-func (s *Session) Subscriptions() (map[string]subscriptionID, error) {
+// principal returns the signed-in user's principal ID, resolving and
+// caching it via Microsoft Graph on first use if it wasn't supplied to
+// NewSession explicitly. Only methods that actually need the principal
+// (activate, deactivate, list-active, eligibility requests) should call
+// this; read-only listings like Subscriptions and RolesAtScope must not,
+// so they keep working for ARM-only access without Graph consent.
+func (s *Session) principal() (string, error) {
+	if s.principalID != "" {
+		return s.principalID, nil
+	}
+	id, err := s.signedInUserID()
+	if err != nil {
+		return "", err
+	}
+	s.principalID = id
+	return id, nil
+}
+
+// signedInUserID returns the principal ID of the signed-in user, resolving
+// it via Microsoft Graph and caching the result on disk when it isn't
+// already cached for the session's tenant.
+func (s *Session) signedInUserID() (string, error) {
+	cacheKey := s.tenantID
+	if cacheKey == "" {
+		cacheKey = "common"
+	}
+	cache, err := loadPrincipalCache()
+	if err != nil {
+		return "", err
+	}
+	if id, found := cache[cacheKey]; found {
+		return id, nil
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+	defer cancel()
+	token, err := s.credential.GetToken(ctx, policy.TokenRequestOptions{
+		Scopes: []string{graphScope},
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, graphMeURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.Token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("graph /me request failed: %s", resp.Status)
+	}
+	var me struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&me); err != nil {
+		return "", err
+	}
+	if me.ID == "" {
+		return "", errors.New("graph /me returned an empty id")
+	}
+
+	cache[cacheKey] = me.ID
+	if err := savePrincipalCache(cache); err != nil {
+		log.Printf("WARNING: failed to cache principal id: %v", err)
+	}
+	return me.ID, nil
+}
+
+// principalCachePath returns the on-disk location of the principal ID
+// cache, e.g. ~/.cache/gazcli/principal.
+func principalCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gazcli", "principal"), nil
+}
+
+// loadPrincipalCache reads the principal ID cache, keyed by tenant. A
+// missing cache file is not an error.
+func loadPrincipalCache() (map[string]string, error) {
+	path, err := principalCachePath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cache := make(map[string]string)
+	if err := json.Unmarshal(b, &cache); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// savePrincipalCache writes the principal ID cache, keyed by tenant.
+func savePrincipalCache(cache map[string]string) error {
+	path, err := principalCachePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(cache, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o600)
+}
+
+// Subscription describes an enabled subscription, as surfaced by the
+// "subscriptions" command.
+type Subscription struct {
+	Name     string `json:"name" yaml:"name"`
+	ID       string `json:"id" yaml:"id"`
+	State    string `json:"state" yaml:"state"`
+	TenantID string `json:"tenantId" yaml:"tenantId"`
+}
+
+// Header implements output.Tabular.
+func (Subscription) Header() []string {
+	return []string{"NAME", "ID", "STATE", "TENANT"}
+}
+
+// Row implements output.Tabular.
+func (sub Subscription) Row() []string {
+	return []string{sub.Name, sub.ID, sub.State, sub.TenantID}
+}
+
+// Subscriptions returns all enabled subscriptions.
+func (s *Session) Subscriptions() ([]Subscription, error) {
 	sc, err := armsubscription.NewSubscriptionsClient(s.credential, nil)
 	if err != nil {
 		return nil, err
@@ -53,50 +260,110 @@ func (s *Session) Subscriptions() (map[string]subscriptionID, error) {
 	pager := sc.NewListPager(&armsubscription.SubscriptionsClientListOptions{})
 	ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
 	defer cancel()
-	var subs = make(map[string]subscriptionID)
+	var subs []Subscription
+	seen := make(map[string]bool)
 	for pager.More() {
 		nextResult, err := pager.NextPage(ctx)
 		if err != nil {
 			return subs, err
 		}
 		for _, v := range nextResult.Value {
-			if v == nil || v.DisplayName == nil || v.SubscriptionID == nil || v.State == nil {
+			if v == nil || v.DisplayName == nil || v.SubscriptionID == nil || v.State == nil || v.TenantID == nil {
 				return subs, errors.New("unexptected nil value returned")
 			}
 			if *v.State != armsubscription.SubscriptionStateEnabled {
 				// ignore all disabled, deleted, etc subscriptions.
 				continue
 			}
-			if _, found := subs[*v.DisplayName]; found {
+			if seen[*v.DisplayName] {
 				log.Printf("WARNING: duplicate subscription ignored: %s",
 					*v.DisplayName)
-			} else {
-				subs[*v.DisplayName] = subscriptionID(*v.SubscriptionID)
+				continue
 			}
+			seen[*v.DisplayName] = true
+			subs = append(subs, Subscription{
+				Name:     *v.DisplayName,
+				ID:       *v.SubscriptionID,
+				State:    string(*v.State),
+				TenantID: *v.TenantID,
+			})
 		}
 	}
 	return subs, nil
 }
 
-// RolesForSubscription lists the available roles that have
-// RoleEligibilitySchedules for the given subscription name.
-func (s *Session) RolesForSubscription(subscriptionName string) ([]string, error) {
+// SubscriptionID resolves the subscription ID for the given subscription
+// display name.
+func (s *Session) SubscriptionID(subscriptionName string) (string, error) {
 	subs, err := s.Subscriptions()
 	if err != nil {
-		return []string{}, err
+		return "", err
 	}
-	sub, found := subs[subscriptionName]
-	if !found {
-		return []string{}, errors.New("subscription not found")
+	for _, sub := range subs {
+		if sub.Name == subscriptionName {
+			return sub.ID, nil
+		}
 	}
+	return "", errors.New("subscription not found")
+}
+
+// Role describes a role eligibility available at a scope, as surfaced by
+// the "roles" command.
+type Role struct {
+	Role             string    `json:"role" yaml:"role"`
+	RoleDefinitionID string    `json:"roleDefinitionId" yaml:"roleDefinitionId"`
+	Scope            string    `json:"scope" yaml:"scope"`
+	EndDateTime      time.Time `json:"endDateTime" yaml:"endDateTime"`
+}
+
+// Header implements output.Tabular.
+func (Role) Header() []string {
+	return []string{"ROLE", "ROLE DEFINITION", "SCOPE", "END"}
+}
 
-	res, err := s.roleEligibilitySchedules(fmt.Sprintf("/subscriptions/%s", sub))
+// Row implements output.Tabular.
+func (r Role) Row() []string {
+	end := ""
+	if !r.EndDateTime.IsZero() {
+		end = r.EndDateTime.Format(time.RFC3339)
+	}
+	return []string{r.Role, r.RoleDefinitionID, r.Scope, end}
+}
+
+// RolesForSubscription lists the available roles that have
+// RoleEligibilitySchedules for the given subscription name.
+func (s *Session) RolesForSubscription(subscriptionName string) ([]Role, error) {
+	subscriptionID, err := s.SubscriptionID(subscriptionName)
 	if err != nil {
-		return []string{}, err
+		return nil, err
 	}
-	roles := []string{}
-	for k := range res {
-		roles = append(roles, k)
+	return s.RolesAtScope(SubscriptionScope(subscriptionID))
+}
+
+// RolesAtScope lists the available roles that have RoleEligibilitySchedules
+// for the given scope.
+func (s *Session) RolesAtScope(scope Scope) ([]Role, error) {
+	res, err := s.roleEligibilitySchedules(scope.String())
+	if err != nil {
+		return nil, err
+	}
+	roles := make([]Role, 0, len(res))
+	for name, re := range res {
+		role := Role{Role: name, Scope: scope.String()}
+		if re.Properties != nil {
+			if re.Properties.RoleDefinitionID != nil {
+				role.RoleDefinitionID = *re.Properties.RoleDefinitionID
+			}
+			if re.Properties.ExpandedProperties != nil &&
+				re.Properties.ExpandedProperties.Scope != nil &&
+				re.Properties.ExpandedProperties.Scope.ID != nil {
+				role.Scope = *re.Properties.ExpandedProperties.Scope.ID
+			}
+			if re.Properties.EndDateTime != nil {
+				role.EndDateTime = *re.Properties.EndDateTime
+			}
+		}
+		roles = append(roles, role)
 	}
 	return roles, nil
 }
@@ -142,38 +409,58 @@ func (s *Session) roleEligibilitySchedules(scope string) (map[string]*armauthori
 	return r, nil
 }
 
+// ActivationResult describes the outcome of scheduling a RoleAssignment, as
+// surfaced by the "activate" command.
+type ActivationResult struct {
+	Name             string    `json:"name" yaml:"name"`
+	RoleDefinitionID string    `json:"roleDefinitionId" yaml:"roleDefinitionId"`
+	Status           string    `json:"status" yaml:"status"`
+	StartDateTime    time.Time `json:"startDateTime" yaml:"startDateTime"`
+}
+
+// Header implements output.Tabular.
+func (ActivationResult) Header() []string {
+	return []string{"NAME", "ROLE DEFINITION", "STATUS", "START"}
+}
+
+// Row implements output.Tabular.
+func (r ActivationResult) Row() []string {
+	return []string{r.Name, r.RoleDefinitionID, r.Status, r.StartDateTime.Format(time.RFC3339)}
+}
+
 // ActiveRoleAssignment will schedule a RoleAssignment for the given
 // roleDisplayName scoped to the subscription.
-func (s *Session) ActiveRoleAssignment(subscriptionName, roleDisplayName, justifiction string, duration time.Duration) error {
-	subs, err := s.Subscriptions()
+func (s *Session) ActiveRoleAssignment(subscriptionName, roleDisplayName, justifiction string, duration time.Duration) (ActivationResult, error) {
+	subscriptionID, err := s.SubscriptionID(subscriptionName)
 	if err != nil {
-		return err
-	}
-	subscriptionID, found := subs[subscriptionName]
-	if !found {
-		return errors.New("subscription not found")
+		return ActivationResult{}, err
 	}
+	return s.ActiveRoleAssignmentAtScope(SubscriptionScope(subscriptionID), roleDisplayName, justifiction, duration)
+}
 
-	res, err := s.roleEligibilitySchedules(fmt.Sprintf("subscriptions/%s", subscriptionID))
+// ActiveRoleAssignmentAtScope will schedule a RoleAssignment for the given
+// roleDisplayName within scope.
+func (s *Session) ActiveRoleAssignmentAtScope(scope Scope, roleDisplayName, justifiction string, duration time.Duration) (ActivationResult, error) {
+	res, err := s.roleEligibilitySchedules(scope.String())
 	if err != nil {
-		return err
+		return ActivationResult{}, err
 	}
 	re, found := res[roleDisplayName]
 	if !found {
-		return errors.New("Role Eligibility Schedule not found")
+		return ActivationResult{}, errors.New("Role Eligibility Schedule not found")
 	}
-	/*
-		if b, err := json.MarshalIndent(re, "", "\t"); err == nil {
-			log.Printf("%s\n", b)
-		}
-	*/
 
 	// Make sure no nil values returned for the fields used below.
 	if re == nil || re.ID == nil || re.Properties == nil ||
 		re.Properties.ExpandedProperties == nil ||
 		re.Properties.ExpandedProperties.RoleDefinition == nil ||
 		re.Properties.RoleDefinitionID == nil {
-		return errors.New("unexptected nil value returned")
+		return ActivationResult{}, errors.New("unexptected nil value returned")
+	}
+
+	principalID, err := s.principal()
+	if err != nil {
+		return ActivationResult{}, err
 	}
 
 	requestType := armauthorization.RequestTypeSelfActivate
@@ -182,7 +469,7 @@ func (s *Session) ActiveRoleAssignment(subscriptionName, roleDisplayName, justif
 	expirationDuration := fmt.Sprintf("PT%dM", int(duration.Minutes()))
 	req := armauthorization.RoleAssignmentScheduleRequest{
 		Properties: &armauthorization.RoleAssignmentScheduleRequestProperties{
-			PrincipalID:      &s.principalID,
+			PrincipalID:      &principalID,
 			RequestType:      &requestType,
 			RoleDefinitionID: re.Properties.RoleDefinitionID,
 			Justification:    &justifiction,
@@ -197,31 +484,366 @@ func (s *Session) ActiveRoleAssignment(subscriptionName, roleDisplayName, justif
 		},
 	}
 
-	scope := fmt.Sprintf("/subscriptions/%s", subscriptionID)
 	guid, err := uuid.NewUUID()
+	if err != nil {
+		return ActivationResult{}, err
+	}
+
+	rasc, err := armauthorization.NewRoleAssignmentScheduleRequestsClient(
+		s.credential, nil)
+	if err != nil {
+		return ActivationResult{}, err
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+	defer cancel()
+	resp, err := rasc.Create(
+		ctx,
+		scope.String(),
+		guid.String(),
+		req,
+		&armauthorization.RoleAssignmentScheduleRequestsClientCreateOptions{},
+	)
+	if err != nil {
+		return ActivationResult{}, err
+	}
+	// Poll using the Schedule Request ID (guid.String(), the name under
+	// which the request was created), not the linked schedule's ID.
+	if err := s.pollRoleAssignmentScheduleRequest(rasc, scope.String(), guid.String()); err != nil {
+		return ActivationResult{}, err
+	}
+
+	result := ActivationResult{Name: guid.String()}
+	if resp.Properties != nil {
+		if resp.Properties.RoleDefinitionID != nil {
+			result.RoleDefinitionID = *resp.Properties.RoleDefinitionID
+		}
+		if resp.Properties.Status != nil {
+			result.Status = string(*resp.Properties.Status)
+		}
+		if resp.Properties.ScheduleInfo != nil && resp.Properties.ScheduleInfo.StartDateTime != nil {
+			result.StartDateTime = *resp.Properties.ScheduleInfo.StartDateTime
+		}
+	}
+	return result, nil
+}
+
+// pollRoleAssignmentScheduleRequest waits for the RoleAssignmentScheduleRequest
+// identified by requestName to reach a terminal state.
+func (s *Session) pollRoleAssignmentScheduleRequest(rasc *armauthorization.RoleAssignmentScheduleRequestsClient, scope, requestName string) error {
+	for i := 0; i < pollAttempts; i++ {
+		ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+		resp, err := rasc.Get(ctx, scope, requestName, nil)
+		cancel()
+		if err != nil {
+			return err
+		}
+		if resp.Properties == nil || resp.Properties.Status == nil {
+			return errors.New("unexptected nil value returned")
+		}
+		switch *resp.Properties.Status {
+		case armauthorization.StatusProvisioned, armauthorization.StatusGranted:
+			return nil
+		case armauthorization.StatusFailed, armauthorization.StatusCanceled, armauthorization.StatusDenied:
+			return fmt.Errorf("role assignment schedule request %s: %s", requestName, *resp.Properties.Status)
+		}
+		time.Sleep(pollInterval)
+	}
+	return fmt.Errorf("timed out waiting for role assignment schedule request %s", requestName)
+}
+
+// DeactivateRoleAssignment releases an active role assignment for the given
+// roleDisplayName scoped to the subscription. If a request for the role is
+// still pending (e.g. an activation awaiting approval), that request is
+// cancelled instead of creating a deactivation request.
+func (s *Session) DeactivateRoleAssignment(subscriptionName, roleDisplayName string) error {
+	subscriptionID, err := s.SubscriptionID(subscriptionName)
 	if err != nil {
 		return err
 	}
+	scope := SubscriptionScope(subscriptionID).String()
 
 	rasc, err := armauthorization.NewRoleAssignmentScheduleRequestsClient(
 		s.credential, nil)
 	if err != nil {
 		return err
 	}
+
+	pending, err := s.pendingRoleAssignmentScheduleRequest(rasc, scope, roleDisplayName)
+	if err != nil {
+		return err
+	}
+	if pending != nil {
+		ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+		defer cancel()
+		_, err := rasc.Cancel(ctx, scope, *pending.Name, nil)
+		return err
+	}
+
+	res, err := s.roleEligibilitySchedules(scope)
+	if err != nil {
+		return err
+	}
+	re, found := res[roleDisplayName]
+	if !found {
+		return errors.New("Role Eligibility Schedule not found")
+	}
+	if re == nil || re.Properties == nil || re.Properties.RoleDefinitionID == nil {
+		return errors.New("unexptected nil value returned")
+	}
+
+	principalID, err := s.principal()
+	if err != nil {
+		return err
+	}
+
+	requestType := armauthorization.RequestTypeSelfDeactivate
+	req := armauthorization.RoleAssignmentScheduleRequest{
+		Properties: &armauthorization.RoleAssignmentScheduleRequestProperties{
+			PrincipalID:      &principalID,
+			RequestType:      &requestType,
+			RoleDefinitionID: re.Properties.RoleDefinitionID,
+		},
+	}
+
+	guid, err := uuid.NewUUID()
+	if err != nil {
+		return err
+	}
 	ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
 	defer cancel()
-	resp, err := rasc.Create(
+	_, err = rasc.Create(
 		ctx,
 		scope,
 		guid.String(),
 		req,
 		&armauthorization.RoleAssignmentScheduleRequestsClientCreateOptions{},
 	)
+	return err
+}
+
+// pendingStatuses are the non-terminal RoleAssignmentScheduleRequest
+// statuses that mean a request is still in flight. A bare "Pending" status
+// does not exist on the API; requests move through several of these states
+// (e.g. waiting on MFA, waiting on an approver) before reaching a terminal
+// one such as Provisioned, Granted, Denied, Canceled or Failed.
+var pendingStatuses = map[armauthorization.Status]bool{
+	armauthorization.StatusAccepted:                   true,
+	armauthorization.StatusPendingEvaluation:           true,
+	armauthorization.StatusPendingApproval:             true,
+	armauthorization.StatusPendingApprovalProvisioning: true,
+	armauthorization.StatusPendingProvisioning:         true,
+	armauthorization.StatusPendingAdminDecision:        true,
+	armauthorization.StatusPendingScheduleCreation:     true,
+	armauthorization.StatusPendingExternalProvisioning: true,
+	armauthorization.StatusProvisioningStarted:         true,
+}
+
+// pendingRoleAssignmentScheduleRequest returns the pending
+// RoleAssignmentScheduleRequest for roleDisplayName at scope, or nil if
+// there isn't one.
+func (s *Session) pendingRoleAssignmentScheduleRequest(rasc *armauthorization.RoleAssignmentScheduleRequestsClient, scope, roleDisplayName string) (*armauthorization.RoleAssignmentScheduleRequest, error) {
+	pager := rasc.NewListForScopePager(scope, &armauthorization.RoleAssignmentScheduleRequestsClientListForScopeOptions{
+		Filter: to.Ptr("asTarget()"),
+	})
+	ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+	defer cancel()
+	for pager.More() {
+		nextResult, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range nextResult.Value {
+			if v == nil || v.Name == nil || v.Properties == nil || v.Properties.Status == nil ||
+				v.Properties.ExpandedProperties == nil ||
+				v.Properties.ExpandedProperties.RoleDefinition == nil ||
+				v.Properties.ExpandedProperties.RoleDefinition.DisplayName == nil {
+				continue
+			}
+			if *v.Properties.ExpandedProperties.RoleDefinition.DisplayName == roleDisplayName &&
+				pendingStatuses[*v.Properties.Status] {
+				return v, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// roleDefinitionID resolves the role definition ID for the built-in or
+// custom role named roleDisplayName at scope. Unlike roleEligibilitySchedules,
+// this does not require an existing RoleEligibilitySchedule, so it can be
+// used to request a brand new eligibility.
+func (s *Session) roleDefinitionID(scope, roleDisplayName string) (string, error) {
+	rdc, err := armauthorization.NewRoleDefinitionsClient(s.credential, nil)
+	if err != nil {
+		return "", err
+	}
+	pager := rdc.NewListPager(scope, &armauthorization.RoleDefinitionsClientListOptions{
+		Filter: to.Ptr(fmt.Sprintf("roleName eq '%s'", roleDisplayName)),
+	})
+	ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+	defer cancel()
+	for pager.More() {
+		nextResult, err := pager.NextPage(ctx)
+		if err != nil {
+			return "", err
+		}
+		for _, v := range nextResult.Value {
+			if v == nil || v.ID == nil {
+				continue
+			}
+			return *v.ID, nil
+		}
+	}
+	return "", errors.New("role definition not found")
+}
+
+// RequestRoleEligibility creates a RoleEligibilityScheduleRequest granting
+// the signed-in user roleDisplayName within scope, expiring after duration.
+// There is no self-service request type for a new eligibility; granting one
+// is an admin action (RequestTypeAdminAssign), so the caller must hold
+// Owner or User Access Administrator at scope.
+func (s *Session) RequestRoleEligibility(scope Scope, roleDisplayName, justifiction string, duration time.Duration) error {
+	roleDefinitionID, err := s.roleDefinitionID(scope.String(), roleDisplayName)
 	if err != nil {
 		return err
 	}
-	if b, err := json.MarshalIndent(resp, "", "\t"); err == nil {
-		log.Printf("%s\n", b)
+	principalID, err := s.principal()
+	if err != nil {
+		return err
 	}
-	return nil
+
+	requestType := armauthorization.RequestTypeAdminAssign
+	startTime := time.Now()
+	expirationType := armauthorization.TypeAfterDuration
+	expirationDuration := fmt.Sprintf("PT%dM", int(duration.Minutes()))
+	req := armauthorization.RoleEligibilityScheduleRequest{
+		Properties: &armauthorization.RoleEligibilityScheduleRequestProperties{
+			PrincipalID:      &principalID,
+			RequestType:      &requestType,
+			RoleDefinitionID: &roleDefinitionID,
+			Justification:    &justifiction,
+			ScheduleInfo: &armauthorization.RoleEligibilityScheduleRequestPropertiesScheduleInfo{
+				StartDateTime: &startTime,
+				Expiration: &armauthorization.RoleEligibilityScheduleRequestPropertiesScheduleInfoExpiration{
+					Type:     &expirationType,
+					Duration: &expirationDuration,
+				},
+			},
+		},
+	}
+	return s.createRoleEligibilityScheduleRequest(scope, req)
+}
+
+// RevokeRoleEligibility removes a previously granted role eligibility for
+// roleDisplayName within scope, using RequestTypeAdminRemove since
+// eligibilities cannot be self-removed.
+func (s *Session) RevokeRoleEligibility(scope Scope, roleDisplayName string) error {
+	roleDefinitionID, err := s.roleDefinitionID(scope.String(), roleDisplayName)
+	if err != nil {
+		return err
+	}
+	principalID, err := s.principal()
+	if err != nil {
+		return err
+	}
+
+	requestType := armauthorization.RequestTypeAdminRemove
+	req := armauthorization.RoleEligibilityScheduleRequest{
+		Properties: &armauthorization.RoleEligibilityScheduleRequestProperties{
+			PrincipalID:      &principalID,
+			RequestType:      &requestType,
+			RoleDefinitionID: &roleDefinitionID,
+		},
+	}
+	return s.createRoleEligibilityScheduleRequest(scope, req)
+}
+
+// createRoleEligibilityScheduleRequest submits req as a new
+// RoleEligibilityScheduleRequest within scope.
+func (s *Session) createRoleEligibilityScheduleRequest(scope Scope, req armauthorization.RoleEligibilityScheduleRequest) error {
+	resc, err := armauthorization.NewRoleEligibilityScheduleRequestsClient(s.credential, nil)
+	if err != nil {
+		return err
+	}
+	guid, err := uuid.NewUUID()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+	defer cancel()
+	_, err = resc.Create(
+		ctx,
+		scope.String(),
+		guid.String(),
+		req,
+		&armauthorization.RoleEligibilityScheduleRequestsClientCreateOptions{},
+	)
+	return err
+}
+
+// ActiveAssignment describes a currently active RoleAssignmentScheduleInstance,
+// as surfaced by the "list-active" command.
+type ActiveAssignment struct {
+	Role  string `json:"role" yaml:"role"`
+	Scope string `json:"scope" yaml:"scope"`
+}
+
+// Header implements output.Tabular.
+func (ActiveAssignment) Header() []string {
+	return []string{"ROLE", "SCOPE"}
+}
+
+// Row implements output.Tabular.
+func (a ActiveAssignment) Row() []string {
+	return []string{a.Role, a.Scope}
+}
+
+// ActiveRoleAssignments lists the RoleAssignmentScheduleInstances currently
+// active for the signed-in principal within the given subscription.
+func (s *Session) ActiveRoleAssignments(subscriptionName string) ([]ActiveAssignment, error) {
+	subscriptionID, err := s.SubscriptionID(subscriptionName)
+	if err != nil {
+		return nil, err
+	}
+	scope := SubscriptionScope(subscriptionID).String()
+	principalID, err := s.principal()
+	if err != nil {
+		return nil, err
+	}
+
+	rasic, err := armauthorization.NewRoleAssignmentScheduleInstancesClient(
+		s.credential, nil)
+	if err != nil {
+		return nil, err
+	}
+	pager := rasic.NewListForScopePager(scope, &armauthorization.RoleAssignmentScheduleInstancesClientListForScopeOptions{
+		Filter: to.Ptr(fmt.Sprintf("principalId eq '%s'", principalID)),
+	})
+	ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+	defer cancel()
+	var roles []ActiveAssignment
+	for pager.More() {
+		nextResult, err := pager.NextPage(ctx)
+		if err != nil {
+			return roles, err
+		}
+		for _, v := range nextResult.Value {
+			if v == nil || v.Properties == nil ||
+				v.Properties.ExpandedProperties == nil ||
+				v.Properties.ExpandedProperties.RoleDefinition == nil ||
+				v.Properties.ExpandedProperties.RoleDefinition.DisplayName == nil {
+				return roles, errors.New("unexptected nil value returned")
+			}
+			assignment := ActiveAssignment{
+				Role:  *v.Properties.ExpandedProperties.RoleDefinition.DisplayName,
+				Scope: scope,
+			}
+			if v.Properties.ExpandedProperties.Scope != nil &&
+				v.Properties.ExpandedProperties.Scope.ID != nil {
+				assignment.Scope = *v.Properties.ExpandedProperties.Scope.ID
+			}
+			roles = append(roles, assignment)
+		}
+	}
+	return roles, nil
 }
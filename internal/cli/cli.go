@@ -8,22 +8,84 @@ import (
 	"time"
 
 	"github.com/basvdlei/gazcli/internal/azure"
+	"github.com/basvdlei/gazcli/internal/config"
+	"github.com/basvdlei/gazcli/internal/output"
+	"github.com/basvdlei/gazcli/internal/tui"
 	cli "github.com/urfave/cli/v2"
 )
 
 var appFlags = []cli.Flag{
 	&cli.StringFlag{
-		Name:  "userid",
-		Usage: "UserID (get it with: 'az ad signed-in-user show -o json | jq .id')",
+		Name:    "userid",
+		Usage:   "UserID, auto-discovered via Microsoft Graph when unset",
+		EnvVars: []string{"GAZCLI_USERID"},
 	},
+	&cli.StringFlag{
+		Name:    "tenant",
+		Usage:   "Azure AD tenant ID, overrides the credential's default tenant",
+		EnvVars: []string{"GAZCLI_TENANT"},
+	},
+	&cli.StringFlag{
+		Name:    "output",
+		Aliases: []string{"o"},
+		Usage:   "Output format: table, json or yaml",
+		Value:   "table",
+		EnvVars: []string{"GAZCLI_OUTPUT"},
+	},
+	&cli.StringFlag{
+		Name:    "profile",
+		Aliases: []string{"p"},
+		Usage:   "Named profile from ~/.config/gazcli/config.yaml to read defaults from",
+		EnvVars: []string{"GAZCLI_PROFILE"},
+	},
+}
+
+// applyProfileDefaults loads the config file and, for every flag the user
+// did not set explicitly, fills it in from the selected profile. It runs as
+// the app's Before hook so every command sees the resolved values.
+func applyProfileDefaults(c *cli.Context) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	profile := cfg.Profile(c.String("profile"))
+	defaults := map[string]string{
+		"userid": profile.UserID,
+		"tenant": profile.TenantID,
+	}
+	for name, value := range defaults {
+		if c.String(name) == "" && value != "" {
+			if err := c.Set(name, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// printRows renders rows using the format requested by the global --output
+// flag.
+func printRows[T output.Tabular](c *cli.Context, items []T) error {
+	rows := make([]output.Tabular, len(items))
+	for i, item := range items {
+		rows[i] = item
+	}
+	return output.NewPrinter(c.String("output")).Print(os.Stdout, rows)
+}
+
+// printRow renders a single row using the format requested by the global
+// --output flag.
+func printRow(c *cli.Context, item output.Tabular) error {
+	return output.NewPrinter(c.String("output")).Print(os.Stdout, item)
 }
+
 var appCommands = []*cli.Command{
 	{
 		Name:    "subscriptions",
 		Aliases: []string{"s"},
 		Usage:   "List enabled subscriptions",
 		Action: func(c *cli.Context) error {
-			s, err := azure.NewSession(c.Context, c.String("userid"))
+			s, err := azure.NewSession(c.Context, c.String("userid"), c.String("tenant"))
 			if err != nil {
 				return err
 			}
@@ -31,17 +93,7 @@ var appCommands = []*cli.Command{
 			if err != nil {
 				return err
 			}
-			/*
-				b, err := json.MarshalIndent(subs, "", "\t")
-				if err != nil {
-					return err
-				}
-				fmt.Printf("%s\n", b)
-			*/
-			for k := range subs {
-				fmt.Println(k)
-			}
-			return nil
+			return printRows(c, subs)
 		},
 	},
 	{
@@ -49,26 +101,34 @@ var appCommands = []*cli.Command{
 		Aliases:   []string{"r"},
 		Usage:     "List available roles",
 		ArgsUsage: "<subscription>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "scope",
+				Usage: "Arbitrary ARM scope, overrides <subscription> (see the 'mg' and 'rg' commands)",
+			},
+		},
 		Action: func(c *cli.Context) error {
-			s, err := azure.NewSession(c.Context, c.String("userid"))
-			if err != nil {
-				return err
-			}
-			roles, err := s.RolesForSubscription(c.Args().Get(0))
+			s, err := azure.NewSession(c.Context, c.String("userid"), c.String("tenant"))
 			if err != nil {
 				return err
 			}
-			/*
-				b, err := json.MarshalIndent(subs, "", "\t")
+			var roles []azure.Role
+			if raw := c.String("scope"); raw != "" {
+				scope, err := azure.ParseScope(raw)
+				if err != nil {
+					return err
+				}
+				roles, err = s.RolesAtScope(scope)
+				if err != nil {
+					return err
+				}
+			} else {
+				roles, err = s.RolesForSubscription(c.Args().Get(0))
 				if err != nil {
 					return err
 				}
-				fmt.Printf("%s\n", b)
-			*/
-			for _, v := range roles {
-				fmt.Println(v)
 			}
-			return nil
+			return printRows(c, roles)
 		},
 	},
 
@@ -77,22 +137,138 @@ var appCommands = []*cli.Command{
 		Aliases:   []string{"a"},
 		Usage:     "Active RoleAssignment for Subscription",
 		ArgsUsage: "<subscription> <role> <justifiction> [duration]",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "scope",
+				Usage: "Arbitrary ARM scope, overrides <subscription> (see the 'mg' and 'rg' commands)",
+			},
+			&cli.BoolFlag{
+				Name:    "interactive",
+				Aliases: []string{"i"},
+				Usage:   "Prompt for subscription, role, justification and duration instead of taking them as arguments",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			args := c.Args()
+			s, err := azure.NewSession(c.Context, c.String("userid"), c.String("tenant"))
+			if err != nil {
+				return err
+			}
+			if c.Bool("interactive") && args.Len() == 0 {
+				return activateInteractive(c, s)
+			}
+			if raw := c.String("scope"); raw != "" {
+				if args.Len() < 2 {
+					return errors.New("not enough arguments")
+				}
+				scope, err := azure.ParseScope(raw)
+				if err != nil {
+					return err
+				}
+				duration := 60 * time.Minute
+				if args.Get(2) != "" {
+					duration, err = time.ParseDuration(args.Get(2))
+					if err != nil {
+						return err
+					}
+				}
+				result, err := s.ActiveRoleAssignmentAtScope(scope, args.Get(0), args.Get(1), duration)
+				if err != nil {
+					return err
+				}
+				return printRow(c, result)
+			}
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			profile := cfg.Profile(c.String("profile"))
+			subName, role, justification, durationArg, err := activateArgs(profile, args)
+			if err != nil {
+				return err
+			}
+			duration := 60 * time.Minute
+			if profile.Duration != "" {
+				duration, err = time.ParseDuration(profile.Duration)
+				if err != nil {
+					return fmt.Errorf("profile has invalid duration %q: %w", profile.Duration, err)
+				}
+			}
+			if durationArg != "" {
+				duration, err = time.ParseDuration(durationArg)
+				if err != nil {
+					return err
+				}
+			}
+			result, err := s.ActiveRoleAssignment(
+				subName,
+				role,
+				justification,
+				duration,
+			)
+			if err != nil {
+				return err
+			}
+			return printRow(c, result)
+		},
+	},
+	{
+		Name:      "deactivate",
+		Aliases:   []string{"d"},
+		Usage:     "Deactivate an active RoleAssignment for Subscription",
+		ArgsUsage: "<subscription> <role>",
 		Action: func(c *cli.Context) error {
-			user := c.String("userid")
-			if user == "" {
-				return errors.New("userid flag not set")
+			args := c.Args()
+			if args.Len() < 2 {
+				return errors.New("not enough arguments")
 			}
+			s, err := azure.NewSession(c.Context, c.String("userid"), c.String("tenant"))
+			if err != nil {
+				return err
+			}
+			return s.DeactivateRoleAssignment(args.Get(0), args.Get(1))
+		},
+	},
+	{
+		Name:      "request-eligible",
+		Usage:     "Request a RoleEligibility for Subscription",
+		ArgsUsage: "<subscription> <role> <justifiction> [duration]",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "scope",
+				Usage: "Arbitrary ARM scope, overrides <subscription> (see the 'mg' and 'rg' commands)",
+			},
+		},
+		Action: func(c *cli.Context) error {
 			args := c.Args()
+			s, err := azure.NewSession(c.Context, c.String("userid"), c.String("tenant"))
+			if err != nil {
+				return err
+			}
+			if raw := c.String("scope"); raw != "" {
+				if args.Len() < 2 {
+					return errors.New("not enough arguments")
+				}
+				scope, err := azure.ParseScope(raw)
+				if err != nil {
+					return err
+				}
+				duration := 60 * time.Minute
+				if args.Get(2) != "" {
+					duration, err = time.ParseDuration(args.Get(2))
+					if err != nil {
+						return err
+					}
+				}
+				return s.RequestRoleEligibility(scope, args.Get(0), args.Get(1), duration)
+			}
 			if args.Len() < 3 {
 				return errors.New("not enough arguments")
 			}
-			s, err := azure.NewSession(c.Context, user)
+			subscriptionID, err := s.SubscriptionID(args.Get(0))
 			if err != nil {
 				return err
 			}
-			subID := args.Get(0)
-			roleDisplayName := args.Get(1)
-			justifiction := args.Get(2)
 			duration := 60 * time.Minute
 			if args.Get(3) != "" {
 				duration, err = time.ParseDuration(args.Get(3))
@@ -100,14 +276,250 @@ var appCommands = []*cli.Command{
 					return err
 				}
 			}
-			return s.ActiveRoleAssignment(
-				subID,
-				roleDisplayName,
-				justifiction,
+			return s.RequestRoleEligibility(
+				azure.SubscriptionScope(subscriptionID),
+				args.Get(1),
+				args.Get(2),
 				duration,
 			)
 		},
 	},
+	{
+		Name:      "revoke-eligible",
+		Usage:     "Revoke a RoleEligibility for Subscription",
+		ArgsUsage: "<subscription> <role>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "scope",
+				Usage: "Arbitrary ARM scope, overrides <subscription> (see the 'mg' and 'rg' commands)",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			args := c.Args()
+			s, err := azure.NewSession(c.Context, c.String("userid"), c.String("tenant"))
+			if err != nil {
+				return err
+			}
+			if raw := c.String("scope"); raw != "" {
+				if args.Len() < 1 {
+					return errors.New("not enough arguments")
+				}
+				scope, err := azure.ParseScope(raw)
+				if err != nil {
+					return err
+				}
+				return s.RevokeRoleEligibility(scope, args.Get(0))
+			}
+			if args.Len() < 2 {
+				return errors.New("not enough arguments")
+			}
+			subscriptionID, err := s.SubscriptionID(args.Get(0))
+			if err != nil {
+				return err
+			}
+			return s.RevokeRoleEligibility(azure.SubscriptionScope(subscriptionID), args.Get(1))
+		},
+	},
+	{
+		Name:      "list-active",
+		Aliases:   []string{"la"},
+		Usage:     "List active RoleAssignments for Subscription",
+		ArgsUsage: "<subscription>",
+		Action: func(c *cli.Context) error {
+			s, err := azure.NewSession(c.Context, c.String("userid"), c.String("tenant"))
+			if err != nil {
+				return err
+			}
+			roles, err := s.ActiveRoleAssignments(c.Args().Get(0))
+			if err != nil {
+				return err
+			}
+			return printRows(c, roles)
+		},
+	},
+	{
+		Name:  "mg",
+		Usage: "List/activate roles at management group scope",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "roles",
+				Aliases:   []string{"r"},
+				Usage:     "List available roles for a management group",
+				ArgsUsage: "<management-group>",
+				Action: func(c *cli.Context) error {
+					s, err := azure.NewSession(c.Context, c.String("userid"), c.String("tenant"))
+					if err != nil {
+						return err
+					}
+					roles, err := s.RolesAtScope(azure.ManagementGroupScope(c.Args().Get(0)))
+					if err != nil {
+						return err
+					}
+					return printRows(c, roles)
+				},
+			},
+			{
+				Name:      "activate",
+				Aliases:   []string{"a"},
+				Usage:     "Activate RoleAssignment for a management group",
+				ArgsUsage: "<management-group> <role> <justifiction> [duration]",
+				Action: func(c *cli.Context) error {
+					args := c.Args()
+					if args.Len() < 3 {
+						return errors.New("not enough arguments")
+					}
+					s, err := azure.NewSession(c.Context, c.String("userid"), c.String("tenant"))
+					if err != nil {
+						return err
+					}
+					duration := 60 * time.Minute
+					if args.Get(3) != "" {
+						duration, err = time.ParseDuration(args.Get(3))
+						if err != nil {
+							return err
+						}
+					}
+					result, err := s.ActiveRoleAssignmentAtScope(
+						azure.ManagementGroupScope(args.Get(0)),
+						args.Get(1),
+						args.Get(2),
+						duration,
+					)
+					if err != nil {
+						return err
+					}
+					return printRow(c, result)
+				},
+			},
+		},
+	},
+	{
+		Name:  "rg",
+		Usage: "List/activate roles at resource group scope",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "roles",
+				Aliases:   []string{"r"},
+				Usage:     "List available roles for a resource group",
+				ArgsUsage: "<subscription> <resource-group>",
+				Action: func(c *cli.Context) error {
+					args := c.Args()
+					if args.Len() < 2 {
+						return errors.New("not enough arguments")
+					}
+					s, err := azure.NewSession(c.Context, c.String("userid"), c.String("tenant"))
+					if err != nil {
+						return err
+					}
+					subID, err := s.SubscriptionID(args.Get(0))
+					if err != nil {
+						return err
+					}
+					roles, err := s.RolesAtScope(azure.ResourceGroupScope(subID, args.Get(1)))
+					if err != nil {
+						return err
+					}
+					return printRows(c, roles)
+				},
+			},
+			{
+				Name:      "activate",
+				Aliases:   []string{"a"},
+				Usage:     "Activate RoleAssignment for a resource group",
+				ArgsUsage: "<subscription> <resource-group> <role> <justifiction> [duration]",
+				Action: func(c *cli.Context) error {
+					args := c.Args()
+					if args.Len() < 4 {
+						return errors.New("not enough arguments")
+					}
+					s, err := azure.NewSession(c.Context, c.String("userid"), c.String("tenant"))
+					if err != nil {
+						return err
+					}
+					subID, err := s.SubscriptionID(args.Get(0))
+					if err != nil {
+						return err
+					}
+					duration := 60 * time.Minute
+					if args.Get(4) != "" {
+						duration, err = time.ParseDuration(args.Get(4))
+						if err != nil {
+							return err
+						}
+					}
+					result, err := s.ActiveRoleAssignmentAtScope(
+						azure.ResourceGroupScope(subID, args.Get(1)),
+						args.Get(2),
+						args.Get(3),
+						duration,
+					)
+					if err != nil {
+						return err
+					}
+					return printRow(c, result)
+				},
+			},
+		},
+	},
+}
+
+// activateArgs resolves the <subscription> <role> <justifiction> [duration]
+// arguments, allowing <subscription> and <justifiction> to be omitted when
+// the active profile sets a default for them. With three or more arguments
+// the first is always taken as <subscription>, so a profile's default
+// subscription is only used when [duration] is not given.
+func activateArgs(profile config.Profile, args cli.Args) (subscription, role, justification, duration string, err error) {
+	switch {
+	case args.Len() >= 3:
+		return args.Get(0), args.Get(1), args.Get(2), args.Get(3), nil
+	case args.Len() == 2 && profile.Subscription != "":
+		return profile.Subscription, args.Get(0), args.Get(1), args.Get(2), nil
+	case args.Len() == 2 && profile.Justification != "":
+		return args.Get(0), args.Get(1), profile.Justification, "", nil
+	case args.Len() == 1 && profile.Subscription != "" && profile.Justification != "":
+		return profile.Subscription, args.Get(0), profile.Justification, "", nil
+	default:
+		return "", "", "", "", errors.New("not enough arguments")
+	}
+}
+
+// activateInteractive prompts the user for a subscription, an eligible role,
+// a justification and a duration instead of reading them from positional
+// arguments.
+func activateInteractive(c *cli.Context, s *azure.Session) error {
+	subs, err := s.Subscriptions()
+	if err != nil {
+		return err
+	}
+	subNames := make([]string, len(subs))
+	for i, sub := range subs {
+		subNames[i] = sub.Name
+	}
+	subName, err := tui.PickSubscription(subNames)
+	if err != nil {
+		return err
+	}
+	roles, err := s.RolesForSubscription(subName)
+	if err != nil {
+		return err
+	}
+	roleNames := make([]string, len(roles))
+	for i, role := range roles {
+		roleNames[i] = role.Role
+	}
+	roleName, err := tui.PickRole(roleNames)
+	if err != nil {
+		return err
+	}
+	params, err := tui.PickActivationParams()
+	if err != nil {
+		return err
+	}
+	result, err := s.ActiveRoleAssignment(subName, roleName, params.Justification, params.Duration)
+	if err != nil {
+		return err
+	}
+	return printRow(c, result)
 }
 
 func NewGazcli() error {
@@ -123,5 +535,6 @@ func NewGazcli() error {
 	app.Name = "gazcli"
 	app.Flags = appFlags
 	app.Commands = appCommands
+	app.Before = applyProfileDefaults
 	return app.Run(os.Args)
 }
@@ -0,0 +1,70 @@
+// Package config loads gazcli's persistent configuration file, which
+// groups the flags that are usually the same on every invocation (tenant,
+// userid, a default subscription, duration and justification) into named
+// profiles.
+package config
+
+import (
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Profile holds the defaults for a single named profile.
+type Profile struct {
+	TenantID      string `yaml:"tenantId"`
+	UserID        string `yaml:"userId"`
+	Subscription  string `yaml:"subscription"`
+	Duration      string `yaml:"duration"`
+	Justification string `yaml:"justification"`
+}
+
+// Config is the parsed contents of config.yaml.
+type Config struct {
+	Default  string             `yaml:"default"`
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// Path returns the location of the config file, ~/.config/gazcli/config.yaml.
+func Path() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gazcli", "config.yaml"), nil
+}
+
+// Load reads and parses the config file. A missing file is not an error; it
+// is treated as an empty configuration.
+func Load() (*Config, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Profile returns the named profile, falling back to the config's default
+// profile when name is empty. It returns the zero Profile when neither is
+// set, so callers can still rely on flags and environment variables alone.
+func (c *Config) Profile(name string) Profile {
+	if name == "" {
+		name = c.Default
+	}
+	if name == "" {
+		return Profile{}
+	}
+	return c.Profiles[name]
+}
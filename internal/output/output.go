@@ -0,0 +1,95 @@
+// Package output renders command results in the format requested via the
+// global --output flag.
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format identifies an output encoding.
+type Format string
+
+// Supported output formats.
+const (
+	JSON  Format = "json"
+	YAML  Format = "yaml"
+	Table Format = "table"
+)
+
+// Tabular is implemented by values that can render as a row in the table
+// output format.
+type Tabular interface {
+	Header() []string
+	Row() []string
+}
+
+// Printer renders v, typically a Tabular value or a slice of one, to w.
+type Printer interface {
+	Print(w io.Writer, v interface{}) error
+}
+
+// NewPrinter returns the Printer for format. An empty or unrecognized
+// format falls back to the table renderer.
+func NewPrinter(format string) Printer {
+	switch Format(format) {
+	case JSON:
+		return jsonPrinter{}
+	case YAML:
+		return yamlPrinter{}
+	default:
+		return tablePrinter{}
+	}
+}
+
+type jsonPrinter struct{}
+
+func (jsonPrinter) Print(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(v)
+}
+
+type yamlPrinter struct{}
+
+func (yamlPrinter) Print(w io.Writer, v interface{}) error {
+	enc := yaml.NewEncoder(w)
+	defer enc.Close()
+	return enc.Encode(v)
+}
+
+type tablePrinter struct{}
+
+func (tablePrinter) Print(w io.Writer, v interface{}) error {
+	rows, err := tabularRows(v)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(rows[0].Header(), "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row.Row(), "\t"))
+	}
+	return tw.Flush()
+}
+
+// tabularRows normalizes v, which may be a single Tabular or a []Tabular,
+// into a slice of Tabular.
+func tabularRows(v interface{}) ([]Tabular, error) {
+	switch rows := v.(type) {
+	case []Tabular:
+		return rows, nil
+	case Tabular:
+		return []Tabular{rows}, nil
+	default:
+		return nil, fmt.Errorf("output: %T does not support the table format", v)
+	}
+}
@@ -0,0 +1,66 @@
+// Package tui provides interactive prompts used by the "-i/--interactive"
+// mode, so users don't have to type exact subscription display names and
+// role names on the command line.
+package tui
+
+import (
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+)
+
+// PickSubscription prompts the user to fuzzy-select one of names.
+func PickSubscription(names []string) (string, error) {
+	return pick("Subscription:", names)
+}
+
+// PickRole prompts the user to fuzzy-select one of names.
+func PickRole(names []string) (string, error) {
+	return pick("Role:", names)
+}
+
+func pick(message string, options []string) (string, error) {
+	var choice string
+	prompt := &survey.Select{
+		Message: message,
+		Options: options,
+	}
+	if err := survey.AskOne(prompt, &choice, survey.WithValidator(survey.Required)); err != nil {
+		return "", err
+	}
+	return choice, nil
+}
+
+// ActivationParams holds the justification and duration collected from the
+// user for an activation request.
+type ActivationParams struct {
+	Justification string
+	Duration      time.Duration
+}
+
+// PickActivationParams prompts the user for a justification and duration.
+func PickActivationParams() (ActivationParams, error) {
+	var answers struct {
+		Justification string
+		Duration      string
+	}
+	qs := []*survey.Question{
+		{
+			Name:     "justification",
+			Prompt:   &survey.Input{Message: "Justification:"},
+			Validate: survey.Required,
+		},
+		{
+			Name:   "duration",
+			Prompt: &survey.Input{Message: "Duration:", Default: "60m"},
+		},
+	}
+	if err := survey.Ask(qs, &answers); err != nil {
+		return ActivationParams{}, err
+	}
+	duration, err := time.ParseDuration(answers.Duration)
+	if err != nil {
+		return ActivationParams{}, err
+	}
+	return ActivationParams{Justification: answers.Justification, Duration: duration}, nil
+}